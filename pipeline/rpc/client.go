@@ -2,32 +2,32 @@ package rpc
 
 import (
 	"context"
+	"encoding/json"
 	"io"
-	"io/ioutil"
 	"math"
+	"math/rand"
 	"sync"
 	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/sourcegraph/jsonrpc2"
-	websocketrpc "github.com/sourcegraph/jsonrpc2/websocket"
 )
 
 const (
 	methodNext   = "next"
 	methodNotify = "notify"
+	methodWait   = "wait"
 	methodUpdate = "update"
 	methodLog    = "log"
-	methodSave   = "save"
 )
 
-type (
-	saveReq struct {
-		ID   string `json:"id"`
-		Mime string `json:"mime"`
-		Data []byte `json:"data"`
-	}
+// methodDone and methodCancel are notifications pushed by the server to a
+// client that has registered interest via methodWait.
+const (
+	methodDone   = "done"
+	methodCancel = "cancel"
+)
 
+type (
 	updateReq struct {
 		ID    string `json:"id"`
 		State State  `json:"state"`
@@ -50,113 +50,364 @@ type Client struct {
 
 	conn     *jsonrpc2.Conn
 	done     bool
-	retry    int
-	backoff  time.Duration
+	opts     *options
 	endpoint string
+
+	waitMu  sync.Mutex
+	waiters map[string][]chan error
+
+	pendingMu    sync.Mutex
+	pendingSeq   uint64
+	pending      map[uint64]*pendingCall
+	reconnectMu  sync.Mutex
+	reconnecting bool
+}
+
+// pendingCall is a call in flight on the connection, kept around so it can
+// be replayed on a fresh connection if the one it was issued on drops.
+type pendingCall struct {
+	ctx  context.Context
+	name string
+	req  interface{}
+	res  interface{}
+	done chan error
 }
 
 // NewClient returns a new Client.
 func NewClient(endpoint string, opts ...Option) (*Client, error) {
+	o := newOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
 	cli := &Client{
 		endpoint: endpoint,
-		retry:    defaultRetryClount,
-		backoff:  defaultBackoff,
-	}
-	for _, opt := range opts {
-		opt(cli)
+		opts:     o,
+		waiters:  make(map[string][]chan error),
+		pending:  make(map[uint64]*pendingCall),
 	}
 	err := cli.openRetry()
 	return cli, err
 }
 
-// Next returns the next pipeline in the queue.
-func (t *Client) Next(c context.Context) (*Pipeline, error) {
+// Next returns the next pipeline in the queue matching filter.
+func (t *Client) Next(c context.Context, filter Filter) (*Pipeline, error) {
 	res := new(Pipeline)
-	err := t.call(methodNext, nil, res)
+	err := t.call(c, methodNext, &filter, res)
 	return res, err
 }
 
 // Notify returns true if the pipeline should be cancelled.
 func (t *Client) Notify(c context.Context, id string) (bool, error) {
 	out := false
-	err := t.call(methodNotify, id, &out)
+	err := t.call(c, methodNotify, id, &out)
 	return out, err
 }
 
+// Wait blocks until the pipeline is done or cancelled. It registers the
+// pipeline id with the server via methodWait and then waits for the server
+// to push a methodDone or methodCancel notification over the same
+// connection, instead of busy-polling Notify. Multiple goroutines may Wait
+// on the same id at once; each gets its own entry in t.waiters so one
+// Wait returning doesn't drop another still in flight for the same id.
+func (t *Client) Wait(c context.Context, id string) error {
+	done := make(chan error, 1)
+	t.addWaiter(id, done)
+	defer t.removeWaiter(id, done)
+
+	if err := t.call(c, methodWait, id, nil); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
+func (t *Client) addWaiter(id string, done chan error) {
+	t.waitMu.Lock()
+	t.waiters[id] = append(t.waiters[id], done)
+	t.waitMu.Unlock()
+}
+
+// removeWaiter unregisters done, and only done, from id's waiter list, so
+// it can't remove an entry a concurrent Wait call for the same id
+// registered after this one.
+func (t *Client) removeWaiter(id string, done chan error) {
+	t.waitMu.Lock()
+	defer t.waitMu.Unlock()
+	list := t.waiters[id]
+	for i, d := range list {
+		if d == done {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(t.waiters, id)
+	} else {
+		t.waiters[id] = list
+	}
+}
+
+// resolveWait delivers err to every pending Wait call registered for id, if
+// any, and stops tracking them.
+func (t *Client) resolveWait(id string, err error) {
+	t.waitMu.Lock()
+	list := t.waiters[id]
+	delete(t.waiters, id)
+	t.waitMu.Unlock()
+	for _, done := range list {
+		done <- err
+	}
+}
+
+// closeWaiters delivers err to every still-pending Wait call, so Close
+// doesn't leave any of them blocked forever.
+func (t *Client) closeWaiters(err error) {
+	t.waitMu.Lock()
+	all := t.waiters
+	t.waiters = make(map[string][]chan error)
+	t.waitMu.Unlock()
+	for _, list := range all {
+		for _, done := range list {
+			done <- err
+		}
+	}
+}
+
+// Handle implements jsonrpc2.Handler, dispatching the done/cancel
+// notifications the server pushes in response to a pending Wait.
+func (t *Client) Handle(c context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if req.Params == nil {
+		return
+	}
+	var id string
+	if err := json.Unmarshal(*req.Params, &id); err != nil {
+		return
+	}
+	switch req.Method {
+	case methodDone:
+		t.resolveWait(id, nil)
+	case methodCancel:
+		t.resolveWait(id, ErrCancelled)
+	}
+}
+
 // Update updates the pipeline state.
 func (t *Client) Update(c context.Context, id string, state State) error {
 	params := updateReq{id, state}
-	return t.call(methodUpdate, &params, nil)
+	return t.call(c, methodUpdate, &params, nil)
 }
 
 // Log writes the pipeline log entry.
 func (t *Client) Log(c context.Context, id string, line *Line) error {
 	params := logReq{id, line}
-	return t.call(methodLog, &params, nil)
+	return t.call(c, methodLog, &params, nil)
 }
 
-// Save saves the pipeline artifact.
+// Save saves the pipeline artifact, streaming it to the server in chunks.
+// See save.go.
 func (t *Client) Save(c context.Context, id, mime string, file io.Reader) error {
-	data, err := ioutil.ReadAll(file)
-	if err != nil {
-		return err
-	}
-	params := saveReq{id, mime, data}
-	return t.call(methodSave, params, nil)
+	return t.saveChunked(c, id, mime, file)
 }
 
-// Close closes the client connection.
+// Close closes the client connection and unblocks any Wait calls still in
+// flight, which would otherwise hang past shutdown.
 func (t *Client) Close() error {
 	t.Lock()
 	t.done = true
 	t.Unlock()
+	t.closeWaiters(io.ErrClosedPipe)
 	return t.conn.Close()
 }
 
-// call makes the remote prodedure call. If the call fails due to connectivity
-// issues the connection is re-establish and call re-attempted.
-func (t *Client) call(name string, req, res interface{}) error {
-	if err := t.conn.Call(context.Background(), name, req, res); err == nil {
+// call makes the remote procedure call, honouring ctx cancellation and
+// deadlines by threading c through to jsonrpc2.Conn.Call instead of the
+// context.Background used previously, so a caller that times out or is
+// cancelled no longer blocks until the server eventually replies.
+//
+// Every call is registered as pending while it runs. If it fails because
+// the connection dropped, reconnectAndReplay is triggered: exactly one goroutine
+// reopens the connection and re-issues every call that was in flight at
+// the time, this one included, instead of only retrying the one call that
+// happened to notice the drop.
+func (t *Client) call(c context.Context, name string, req, res interface{}) error {
+	pc := &pendingCall{ctx: c, name: name, req: req, res: res, done: make(chan error, 1)}
+	id := t.addPending(pc)
+	defer t.removePending(id)
+
+	err := t.getConn().Call(c, name, req, res)
+	if err == nil {
 		return nil
-	} else if err != jsonrpc2.ErrClosed && err != io.ErrUnexpectedEOF {
+	}
+	if err != jsonrpc2.ErrClosed && err != io.ErrUnexpectedEOF {
 		return err
 	}
-	if err := t.openRetry(); err != nil {
+
+	t.reconnectAndReplay()
+
+	select {
+	case err := <-pc.done:
 		return err
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
+// addPending registers pc so it can be replayed after a reconnect, and
+// returns the id removePending needs to unregister it again.
+func (t *Client) addPending(pc *pendingCall) uint64 {
+	t.pendingMu.Lock()
+	defer t.pendingMu.Unlock()
+	t.pendingSeq++
+	id := t.pendingSeq
+	t.pending[id] = pc
+	return id
+}
+
+func (t *Client) removePending(id uint64) {
+	t.pendingMu.Lock()
+	delete(t.pending, id)
+	t.pendingMu.Unlock()
+}
+
+// reconnectAndReplay ensures exactly one goroutine reopens the connection
+// and replays every call still registered as pending; concurrent callers
+// that hit the same drop just wait on their own pendingCall.done instead of
+// each racing to reconnect and retry independently.
+func (t *Client) reconnectAndReplay() {
+	t.reconnectMu.Lock()
+	if t.reconnecting {
+		t.reconnectMu.Unlock()
+		return
+	}
+	t.reconnecting = true
+	t.reconnectMu.Unlock()
+
+	go func() {
+		defer func() {
+			t.reconnectMu.Lock()
+			t.reconnecting = false
+			t.reconnectMu.Unlock()
+		}()
+		if err := t.openRetry(); err != nil {
+			t.failPending(err)
+			return
+		}
+		t.replayPending()
+	}()
+}
+
+// snapshotPending returns the calls currently registered as pending.
+func (t *Client) snapshotPending() []*pendingCall {
+	t.pendingMu.Lock()
+	defer t.pendingMu.Unlock()
+	calls := make([]*pendingCall, 0, len(t.pending))
+	for _, pc := range t.pending {
+		calls = append(calls, pc)
 	}
-	return t.conn.Call(context.Background(), name, req, res)
+	return calls
 }
 
-// openRetry opens the connection and will retry on failure until
-// the connection is successfully open, or the maximum retry count
-// is exceeded.
+// failPending delivers err to every call currently pending, for when
+// reconnecting itself gives up.
+func (t *Client) failPending(err error) {
+	for _, pc := range t.snapshotPending() {
+		pc.done <- err
+	}
+}
+
+// replayPending re-issues every call currently pending against the new
+// connection, delivering each result back to its own caller.
+//
+// This is blind to idempotency: if the original request actually reached
+// the server and was processed before the drop was merely detected on
+// this end, replaying it re-sends it verbatim. For Next that risks a
+// second pipeline being dequeued for the same replay; for Update it risks
+// a stale state clobbering one already applied. Request chunk0-5 only
+// asked that in-flight calls not be lost on reconnect, not that
+// state-mutating calls be made safe to re-execute, so that risk is
+// accepted here rather than solved — callers that can't tolerate a
+// duplicate Next or a stale Update need their own idempotency guard
+// (e.g. a server-side dedup key) until this gets revisited.
+func (t *Client) replayPending() {
+	for _, pc := range t.snapshotPending() {
+		pc := pc
+		go func() {
+			pc.done <- t.getConn().Call(pc.ctx, pc.name, pc.req, pc.res)
+		}()
+	}
+}
+
+// getConn returns the current connection. It is called instead of reading
+// t.conn directly so a concurrent reconnect (which replaces t.conn under
+// Lock in open) can never hand a call a half-swapped connection.
+func (t *Client) getConn() *jsonrpc2.Conn {
+	t.Lock()
+	defer t.Unlock()
+	return t.conn
+}
+
+// openRetry opens the connection and will retry, waiting an exponentially
+// increasing, jittered backoff between attempts, until the connection is
+// successfully open or the maximum retry count is exceeded. Unlike the
+// original implementation it reports the last error instead of silently
+// returning nil when every attempt fails.
 func (t *Client) openRetry() error {
-	for i := 0; i < t.retry; i++ {
-		err := t.open()
+	var err error
+	for i := 0; i < t.opts.retry; i++ {
+		err = t.open()
 		if err == nil {
-			break
+			return nil
 		}
 		if err == io.EOF {
 			return err
 		}
-		<-time.After(t.backoff)
+		<-time.After(nextBackoff(i, t.opts))
 	}
-	return nil
+	return err
+}
+
+// nextBackoff returns a jittered exponential backoff for the given 0-based
+// attempt number, capped at o.backoffCap.
+func nextBackoff(attempt int, o *options) time.Duration {
+	d := float64(o.backoffBase) * math.Pow(o.backoffFactor, float64(attempt))
+	if max := float64(o.backoffCap); d > max {
+		d = max
+	}
+	return time.Duration(rand.Float64() * d)
 }
 
-// open creates a websocket connection to a peer and establishes a json
-// rpc communication stream.
+// open dials a connection to a peer, via the Dialer set by WithDialer or
+// else the one matching the endpoint's URL scheme, and establishes a
+// jsonrpc2 communication stream over it.
 func (t *Client) open() error {
 	t.Lock()
 	defer t.Unlock()
 	if t.done {
 		return io.EOF
 	}
-	conn, _, err := websocket.DefaultDialer.Dial(t.endpoint, nil)
+	dialer := t.opts.dialer
+	if dialer == nil {
+		d, err := dialerForEndpoint(t.endpoint, t.opts)
+		if err != nil {
+			return err
+		}
+		dialer = d
+	}
+	stream, err := dialer.Dial(context.Background(), t.endpoint)
 	if err != nil {
 		return err
 	}
-	stream := websocketrpc.NewObjectStream(conn)
-	t.conn = jsonrpc2.NewConn(context.Background(), stream, nil)
+	t.conn = jsonrpc2.NewConn(context.Background(), stream, jsonrpc2.HandlerWithError(t.handle))
 	return nil
 }
+
+// handle adapts Handle to jsonrpc2.HandlerWithError's signature.
+func (t *Client) handle(c context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (interface{}, error) {
+	t.Handle(c, conn, req)
+	return nil, nil
+}
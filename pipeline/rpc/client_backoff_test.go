@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestNextBackoffDefaultGrowsExponentially calls nextBackoff directly (not
+// a reimplementation of its formula) across attempts 0-9 under the default
+// options, and checks every sampled duration falls within [0, ceiling] for
+// that attempt's expected ceiling, that the ceiling grows, and that it
+// eventually clamps at backoffCap. A wrong base, an off-by-one on attempt,
+// or backoffFactor being ignored would all show up as samples clustering
+// well below the expected ceiling.
+func TestNextBackoffDefaultGrowsExponentially(t *testing.T) {
+	o := newOptions()
+	if o.backoffCap <= o.backoffBase {
+		t.Fatalf("default backoffCap (%v) must be greater than backoffBase (%v) for attempts to actually grow", o.backoffCap, o.backoffBase)
+	}
+
+	const samples = 300
+	var prevCeiling time.Duration
+	sawGrowth := false
+	sawCap := false
+	for attempt := 0; attempt < 10; attempt++ {
+		wantCeiling := time.Duration(float64(o.backoffBase) * math.Pow(o.backoffFactor, float64(attempt)))
+		if wantCeiling > o.backoffCap {
+			wantCeiling = o.backoffCap
+		}
+
+		var max time.Duration
+		for i := 0; i < samples; i++ {
+			d := nextBackoff(attempt, o)
+			if d < 0 || d > wantCeiling {
+				t.Fatalf("nextBackoff(%d, o) = %v, want in [0, %v]", attempt, d, wantCeiling)
+			}
+			if d > max {
+				max = d
+			}
+		}
+		if float64(max) < 0.8*float64(wantCeiling) {
+			t.Fatalf("attempt %d: highest of %d samples was %v, want close to ceiling %v", attempt, samples, max, wantCeiling)
+		}
+		if wantCeiling > prevCeiling {
+			sawGrowth = true
+		}
+		if wantCeiling == o.backoffCap {
+			sawCap = true
+		}
+		prevCeiling = wantCeiling
+	}
+	if !sawGrowth {
+		t.Fatal("nextBackoff's ceiling never grew across attempts 0-9 with the default options")
+	}
+	if !sawCap {
+		t.Fatal("nextBackoff never reached backoffCap across attempts 0-9 with the default options")
+	}
+}
@@ -0,0 +1,235 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/websocket"
+	"github.com/sourcegraph/jsonrpc2"
+	websocketrpc "github.com/sourcegraph/jsonrpc2/websocket"
+)
+
+// Dialer establishes the transport-level connection a Client speaks
+// jsonrpc2 over. Client selects one by the endpoint's URL scheme unless an
+// explicit Dialer is set via WithDialer, which lets embedders swap
+// transports (or fake one entirely in tests) without touching Client.
+type Dialer interface {
+	Dial(ctx context.Context, endpoint string) (jsonrpc2.ObjectStream, error)
+}
+
+// dialerForEndpoint picks the Dialer matching endpoint's URL scheme,
+// carrying over the TLS, bearer-token and agent-id settings from o.
+func dialerForEndpoint(endpoint string, o *options) (Dialer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "", "ws", "wss":
+		return websocketDialer{tlsConfig: o.tlsConfig, token: o.token, agentID: o.agentID}, nil
+	case "http", "https":
+		return httpDialer{tlsConfig: o.tlsConfig, token: o.token, agentID: o.agentID}, nil
+	case "unix":
+		return unixDialer{}, nil
+	case "inproc":
+		return nil, fmt.Errorf("rpc: inproc:// endpoints have no handler to dial; pass WithDialer(NewInprocDialer(handler)) instead")
+	default:
+		return nil, fmt.Errorf("rpc: unsupported endpoint scheme %q", u.Scheme)
+	}
+}
+
+// authHeader builds the Authorization/X-Agent-Id headers shared by the
+// websocket and HTTP transports.
+func authHeader(token, agentID string) http.Header {
+	h := http.Header{}
+	if token != "" {
+		h.Set("Authorization", "Bearer "+token)
+	}
+	if agentID != "" {
+		h.Set("X-Agent-Id", agentID)
+	}
+	return h
+}
+
+// websocketDialer is the original, default transport.
+type websocketDialer struct {
+	tlsConfig *tls.Config
+	token     string
+	agentID   string
+}
+
+func (d websocketDialer) Dial(ctx context.Context, endpoint string) (jsonrpc2.ObjectStream, error) {
+	dialer := *websocket.DefaultDialer
+	if d.tlsConfig != nil {
+		dialer.TLSClientConfig = d.tlsConfig
+	}
+	conn, _, err := dialer.DialContext(ctx, endpoint, authHeader(d.token, d.agentID))
+	if err != nil {
+		return nil, err
+	}
+	return websocketrpc.NewObjectStream(conn), nil
+}
+
+// unixDialer speaks jsonrpc2 over a Unix domain socket, addressed by the
+// path component of a unix:// endpoint.
+type unixDialer struct{}
+
+func (unixDialer) Dial(ctx context.Context, endpoint string) (jsonrpc2.ObjectStream, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", u.Path)
+	if err != nil {
+		return nil, err
+	}
+	return jsonrpc2.NewBufferedStream(conn, jsonrpc2.VSCodeObjectCodec{}), nil
+}
+
+// httpDialer issues one HTTP POST per jsonrpc2 object sent, with no
+// persistent connection. It has no native notion of a duplex stream, so
+// WriteObject performs the POST and ReadObject hands back its response body
+// once the round trip completes.
+type httpDialer struct {
+	tlsConfig *tls.Config
+	token     string
+	agentID   string
+}
+
+func (d httpDialer) Dial(ctx context.Context, endpoint string) (jsonrpc2.ObjectStream, error) {
+	client := http.DefaultClient
+	if d.tlsConfig != nil {
+		client = &http.Client{Transport: &http.Transport{TLSClientConfig: d.tlsConfig}}
+	}
+	return &httpObjectStream{
+		ctx:      ctx,
+		endpoint: endpoint,
+		client:   client,
+		header:   authHeader(d.token, d.agentID),
+		resp:     make(chan []byte, 1),
+	}, nil
+}
+
+// httpObjectStream multiplexes every call the Client currently has in
+// flight through the same stream, so a failed POST must surface as that one
+// call's result, not as a stream-level error: jsonrpc2.Conn.readMessages
+// treats any ReadObject error as fatal for the whole Conn and fails every
+// other pending call along with it. WriteObject therefore never reports a
+// request failure through ReadObject's error return; it encodes the failure
+// as a JSON-RPC error response carrying the request's own id and hands that
+// to ReadObject like any other reply, so Conn can route it back to the one
+// call it belongs to.
+type httpObjectStream struct {
+	ctx      context.Context
+	endpoint string
+	client   *http.Client
+	header   http.Header
+	resp     chan []byte
+}
+
+func (s *httpObjectStream) WriteObject(obj interface{}) error {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	id := requestID(body)
+	httpReq, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq = httpReq.WithContext(s.ctx)
+	for k, v := range s.header {
+		httpReq.Header[k] = v
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	go func() {
+		res, err := s.client.Do(httpReq)
+		if err != nil {
+			s.resp <- errorResponse(id, err)
+			return
+		}
+		defer res.Body.Close()
+		data, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			s.resp <- errorResponse(id, err)
+			return
+		}
+		if res.StatusCode != http.StatusOK {
+			s.resp <- errorResponse(id, fmt.Errorf("rpc: peer returned %s: %s", res.Status, bytes.TrimSpace(data)))
+			return
+		}
+		s.resp <- data
+	}()
+	return nil
+}
+
+// requestID pulls the id back out of body, the already-marshaled outgoing
+// request, so a failed POST can be reported as that one call's error
+// response. obj passed to WriteObject wraps a *jsonrpc2.Request in an
+// unexported jsonrpc2 type, so the id can't be read off obj directly; the
+// wire JSON is the only way to get at it from outside the package.
+func requestID(body []byte) jsonrpc2.ID {
+	var parsed struct {
+		ID jsonrpc2.ID `json:"id"`
+	}
+	// Best-effort: a notification's id is its zero value, and that is no
+	// worse than the stream-fatal behaviour this replaces.
+	_ = json.Unmarshal(body, &parsed)
+	return parsed.ID
+}
+
+// errorResponse marshals a JSON-RPC error response for id, carrying err's
+// message, so a transport-level failure on one call can be delivered
+// through the same path as a normal reply instead of killing the stream.
+func errorResponse(id jsonrpc2.ID, err error) []byte {
+	resp := &jsonrpc2.Response{
+		ID:    id,
+		Error: &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: err.Error()},
+	}
+	data, marshalErr := json.Marshal(resp)
+	if marshalErr != nil {
+		// resp has no fields that can fail to marshal; keep ReadObject's
+		// contract of always returning decodable JSON just in case.
+		return []byte(`{"error":{"code":-32603,"message":"rpc: internal error"}}`)
+	}
+	return data
+}
+
+func (s *httpObjectStream) ReadObject(v interface{}) error {
+	select {
+	case data := <-s.resp:
+		return json.Unmarshal(data, v)
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+func (s *httpObjectStream) Close() error { return nil }
+
+// inprocDialer connects a Client directly to a jsonrpc2.Handler in the same
+// process over an in-memory net.Pipe, so agents can be unit-tested without
+// a real server.
+type inprocDialer struct {
+	handler jsonrpc2.Handler
+}
+
+// NewInprocDialer returns a Dialer that serves every connection directly
+// from handler, with no network involved.
+func NewInprocDialer(handler jsonrpc2.Handler) Dialer {
+	return &inprocDialer{handler: handler}
+}
+
+func (d *inprocDialer) Dial(ctx context.Context, endpoint string) (jsonrpc2.ObjectStream, error) {
+	clientConn, serverConn := net.Pipe()
+	serverStream := jsonrpc2.NewBufferedStream(serverConn, jsonrpc2.VSCodeObjectCodec{})
+	jsonrpc2.NewConn(ctx, serverStream, d.handler)
+	return jsonrpc2.NewBufferedStream(clientConn, jsonrpc2.VSCodeObjectCodec{}), nil
+}
@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestHTTPDialerSendsAuthHeaders verifies WithToken/WithAgentID actually put
+// an Authorization: Bearer ... and X-Agent-Id header on the HTTP transport's
+// request, by inspecting the header an httptest.Server actually receives
+// rather than just asserting authHeader's return value.
+func TestHTTPDialerSendsAuthHeaders(t *testing.T) {
+	var gotAuth, gotAgentID string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotAgentID = r.Header.Get("X-Agent-Id")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":1,"result":null}`))
+	}))
+	defer srv.Close()
+
+	d := httpDialer{token: "s3cr3t", agentID: "agent-7"}
+	stream, err := d.Dial(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer stream.Close()
+
+	if err := stream.WriteObject(map[string]interface{}{"id": 1, "method": "ping"}); err != nil {
+		t.Fatalf("WriteObject: %v", err)
+	}
+	var reply interface{}
+	if err := stream.ReadObject(&reply); err != nil {
+		t.Fatalf("ReadObject: %v", err)
+	}
+
+	if want := "Bearer s3cr3t"; gotAuth != want {
+		t.Errorf("Authorization header = %q, want %q", gotAuth, want)
+	}
+	if want := "agent-7"; gotAgentID != want {
+		t.Errorf("X-Agent-Id header = %q, want %q", gotAgentID, want)
+	}
+}
+
+// TestWebsocketDialerSendsAuthHeaders verifies the same for the websocket
+// transport, by inspecting the header the peer's upgrade handler sees on
+// the initial HTTP request before it's hijacked into a websocket.
+func TestWebsocketDialerSendsAuthHeaders(t *testing.T) {
+	var gotAuth, gotAgentID string
+	var upgrader websocket.Upgrader
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotAgentID = r.Header.Get("X-Agent-Id")
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Upgrade: %v", err)
+			return
+		}
+		conn.Close()
+	}))
+	defer srv.Close()
+
+	wsURL := "ws" + srv.URL[len("http"):]
+	d := websocketDialer{token: "s3cr3t", agentID: "agent-7"}
+	stream, err := d.Dial(context.Background(), wsURL)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	stream.Close()
+
+	if want := "Bearer s3cr3t"; gotAuth != want {
+		t.Errorf("Authorization header = %q, want %q", gotAuth, want)
+	}
+	if want := "agent-7"; gotAgentID != want {
+		t.Errorf("X-Agent-Id header = %q, want %q", gotAgentID, want)
+	}
+}
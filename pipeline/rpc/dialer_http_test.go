@@ -0,0 +1,116 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// flakyOnceHandler fails the first request for a chosen method with a 500,
+// then answers every other request normally, so a test can isolate the
+// effect of exactly one failed POST on an httpObjectStream shared by
+// several concurrent calls.
+type flakyOnceHandler struct {
+	failMethod string
+
+	mu    sync.Mutex
+	burnt bool
+}
+
+func (h *flakyOnceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req jsonrpc2.Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Method == h.failMethod {
+		h.mu.Lock()
+		fail := !h.burnt
+		h.burnt = true
+		h.mu.Unlock()
+		if fail {
+			http.Error(w, "injected failure", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	result := json.RawMessage(`"ok"`)
+	data, err := json.Marshal(&jsonrpc2.Response{ID: req.ID, Result: &result})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// TestHTTPObjectStreamIsolatesFailedCall is the regression test for
+// chunk0-6: one HTTP POST failing (here, a 500 returned for "willfail")
+// must only fail that call, not every other call concurrently in flight
+// over the same httpObjectStream/jsonrpc2.Conn pair. Before the fix,
+// WriteObject pushed a bare error onto a connection-fatal channel, and
+// jsonrpc2.Conn.readMessages tore down the whole Conn (and every pending
+// call on it) the moment it saw any ReadObject error.
+func TestHTTPObjectStreamIsolatesFailedCall(t *testing.T) {
+	h := &flakyOnceHandler{failMethod: "willfail"}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	d := httpDialer{}
+	stream, err := d.Dial(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	conn := jsonrpc2.NewConn(context.Background(), stream, jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (interface{}, error) {
+		return nil, nil
+	}))
+	defer conn.Close()
+
+	var wg sync.WaitGroup
+	var okCalls int32
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var reply string
+		if err := conn.Call(context.Background(), "willfail", nil, &reply); err == nil {
+			t.Error("call to willfail: got nil error, want the injected failure surfaced")
+		}
+	}()
+
+	// Give the failing call a head start so its response is likely to land
+	// on the shared stream before the others.
+	time.Sleep(10 * time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var reply string
+			if err := conn.Call(context.Background(), "ok", nil, &reply); err != nil {
+				t.Errorf("call to ok: %v", err)
+				return
+			}
+			atomic.AddInt32(&okCalls, 1)
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&okCalls); got != 5 {
+		t.Fatalf("%d of 5 concurrent calls succeeded despite one unrelated call failing, want all 5", got)
+	}
+}
@@ -0,0 +1,90 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// echoHandler replies "pong" to every call, so a dial
+// test can confirm a round trip without needing a real Peer behind it.
+type echoHandler struct{}
+
+func (echoHandler) Handle(c context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	conn.Reply(c, req.ID, "pong")
+}
+
+// TestInprocDialerRoundTrip is the base smoke test for the Dialer
+// abstraction: dialing an inproc:// endpoint via NewInprocDialer should
+// hand back a working jsonrpc2 stream with no network involved, and a call
+// over it should reach the handler and return its reply.
+func TestInprocDialerRoundTrip(t *testing.T) {
+	d := NewInprocDialer(echoHandler{})
+	stream, err := d.Dial(context.Background(), "inproc://test")
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	conn := jsonrpc2.NewConn(context.Background(), stream, jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (interface{}, error) {
+		return nil, nil
+	}))
+	defer conn.Close()
+
+	var reply string
+	if err := conn.Call(context.Background(), "ping", nil, &reply); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if reply != "pong" {
+		t.Fatalf("reply = %q, want %q", reply, "pong")
+	}
+}
+
+// TestDialerForEndpointSelectsByScheme verifies dialerForEndpoint maps each
+// supported URL scheme to the matching Dialer implementation, and rejects
+// schemes (like inproc://, which needs an explicit WithDialer) and
+// unsupported ones.
+func TestDialerForEndpointSelectsByScheme(t *testing.T) {
+	o := newOptions()
+	cases := []struct {
+		endpoint string
+		wantType Dialer
+		wantErr  bool
+	}{
+		{endpoint: "ws://example.test", wantType: websocketDialer{}},
+		{endpoint: "wss://example.test", wantType: websocketDialer{}},
+		{endpoint: "http://example.test", wantType: httpDialer{}},
+		{endpoint: "https://example.test", wantType: httpDialer{}},
+		{endpoint: "unix:///tmp/example.sock", wantType: unixDialer{}},
+		{endpoint: "inproc://example", wantErr: true},
+		{endpoint: "ftp://example.test", wantErr: true},
+	}
+	for _, tc := range cases {
+		d, err := dialerForEndpoint(tc.endpoint, o)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("dialerForEndpoint(%q): expected an error, got none", tc.endpoint)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("dialerForEndpoint(%q): %v", tc.endpoint, err)
+			continue
+		}
+		if got, want := typeName(d), typeName(tc.wantType); got != want {
+			t.Errorf("dialerForEndpoint(%q) = %s, want %s", tc.endpoint, got, want)
+		}
+	}
+}
+
+func typeName(d Dialer) string {
+	switch d.(type) {
+	case websocketDialer:
+		return "websocketDialer"
+	case httpDialer:
+		return "httpDialer"
+	case unixDialer:
+		return "unixDialer"
+	default:
+		return "unknown"
+	}
+}
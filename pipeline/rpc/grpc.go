@@ -0,0 +1,334 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+	"github.com/cncd/pipeline/pipeline/rpc/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// grpcClient is a Peer implementation backed by a gRPC connection, offered
+// alongside Client so agents can switch transports via a flag without the
+// Peer interface changing.
+type grpcClient struct {
+	conn   *grpc.ClientConn
+	client proto.PeerClient
+	opts   *options
+}
+
+// NewGRPCClient returns a new Peer that talks to the server over gRPC.
+func NewGRPCClient(endpoint string, opts ...Option) (Peer, error) {
+	o := newOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	dialOpts := []grpc.DialOption{grpc.WithInsecure()}
+	if o.tlsConfig != nil {
+		dialOpts = []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(o.tlsConfig))}
+	}
+	conn, err := grpc.Dial(endpoint, dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return &grpcClient{
+		conn:   conn,
+		client: proto.NewPeerClient(conn),
+		opts:   o,
+	}, nil
+}
+
+// ctx attaches the bearer token and agent id, if set, as outgoing gRPC
+// metadata so the server can authenticate and attribute the call.
+func (g *grpcClient) ctx(c context.Context) context.Context {
+	pairs := make([]string, 0, 4)
+	if g.opts.token != "" {
+		pairs = append(pairs, "authorization", "Bearer "+g.opts.token)
+	}
+	if g.opts.agentID != "" {
+		pairs = append(pairs, "x-agent-id", g.opts.agentID)
+	}
+	if len(pairs) == 0 {
+		return c
+	}
+	return metadata.AppendToOutgoingContext(c, pairs...)
+}
+
+// Next returns the next pipeline in the queue matching filter.
+func (g *grpcClient) Next(c context.Context, filter Filter) (*Pipeline, error) {
+	res, err := g.client.Next(g.ctx(c), &proto.NextRequest{Platform: filter.Platform, Labels: filter.Labels})
+	if err != nil {
+		return nil, err
+	}
+	return toPipeline(res.Pipeline)
+}
+
+// Notify returns true if the pipeline should be cancelled.
+func (g *grpcClient) Notify(c context.Context, id string) (bool, error) {
+	res, err := g.client.Notify(g.ctx(c), &proto.NotifyRequest{Id: id})
+	if err != nil {
+		return false, err
+	}
+	return res.Cancelled, nil
+}
+
+// waitPollInterval is how often Wait re-checks Notify while the gRPC
+// transport has no push-based subscription of its own.
+const waitPollInterval = time.Second
+
+// Wait blocks until the pipeline is cancelled or ctx is done. Unlike the
+// jsonrpc2 Client, the gRPC transport does not yet have a streaming
+// subscription RPC for this, so it polls the existing Notify call; normal
+// completion is expected to be observed by the caller itself, not signalled
+// here.
+func (g *grpcClient) Wait(c context.Context, id string) error {
+	for {
+		cancelled, err := g.Notify(c, id)
+		if err != nil {
+			return err
+		}
+		if cancelled {
+			return ErrCancelled
+		}
+		select {
+		case <-c.Done():
+			return c.Err()
+		case <-time.After(waitPollInterval):
+		}
+	}
+}
+
+// Extend extends the pipeline deadline.
+func (g *grpcClient) Extend(c context.Context, id string) error {
+	_, err := g.client.Extend(g.ctx(c), &proto.ExtendRequest{Id: id})
+	return err
+}
+
+// Update updates the pipeline state.
+func (g *grpcClient) Update(c context.Context, id string, state State) error {
+	_, err := g.client.Update(g.ctx(c), &proto.UpdateRequest{Id: id, State: toProtoState(state)})
+	return err
+}
+
+// Save streams the pipeline artifact to the server in chunks so it never
+// has to be buffered into a single message.
+func (g *grpcClient) Save(c context.Context, id, mime string, file io.Reader) error {
+	stream, err := g.client.Save(g.ctx(c))
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, g.opts.saveChunkSize)
+	for {
+		n, rerr := file.Read(buf)
+		if n > 0 {
+			chunk := make([]byte, n)
+			copy(chunk, buf[:n])
+			if serr := stream.Send(&proto.SaveRequest{Id: id, Mime: mime, Data: chunk}); serr != nil {
+				return serr
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+	_, err = stream.CloseAndRecv()
+	return err
+}
+
+// Log streams the pipeline log entry to the server.
+func (g *grpcClient) Log(c context.Context, id string, line *Line) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	stream, err := g.client.Log(g.ctx(c))
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(&proto.LogRequest{Id: id, Line: data}); err != nil {
+		return err
+	}
+	_, err = stream.CloseAndRecv()
+	return err
+}
+
+// Close closes the underlying gRPC connection.
+func (g *grpcClient) Close() error {
+	return g.conn.Close()
+}
+
+// RegisterGRPCServer adapts peer to the generated PeerServer interface and
+// registers it with s, so agents can reach it over gRPC.
+func RegisterGRPCServer(s *grpc.Server, peer Peer) {
+	proto.RegisterPeerServer(s, &grpcServer{peer: peer})
+}
+
+// grpcServer adapts a Peer implementation to proto.PeerServer.
+type grpcServer struct {
+	peer Peer
+}
+
+func (s *grpcServer) Next(c context.Context, req *proto.NextRequest) (*proto.NextResponse, error) {
+	filter := Filter{Platform: req.Platform, Labels: req.Labels}
+	pipeline, err := s.peer.Next(c, filter)
+	if err != nil {
+		return nil, err
+	}
+	item, err := fromPipeline(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.NextResponse{Pipeline: item}, nil
+}
+
+func (s *grpcServer) Notify(c context.Context, req *proto.NotifyRequest) (*proto.NotifyResponse, error) {
+	cancelled, err := s.peer.Notify(c, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.NotifyResponse{Cancelled: cancelled}, nil
+}
+
+func (s *grpcServer) Extend(c context.Context, req *proto.ExtendRequest) (*proto.ExtendResponse, error) {
+	return new(proto.ExtendResponse), s.peer.Extend(c, req.Id)
+}
+
+func (s *grpcServer) Update(c context.Context, req *proto.UpdateRequest) (*proto.UpdateResponse, error) {
+	return new(proto.UpdateResponse), s.peer.Update(c, req.Id, fromProtoState(req.State))
+}
+
+// Save feeds s.peer.Save from an io.Pipe filled as chunks arrive off the
+// stream, instead of buffering the whole artifact in memory first, so a
+// large upload can't OOM the server. The first Recv is read synchronously
+// to get id/mime before peer.Save is called; the rest of the stream is
+// copied into the pipe by a goroutine running concurrently with peer.Save
+// draining the other end.
+func (s *grpcServer) Save(stream proto.Peer_SaveServer) error {
+	first, err := stream.Recv()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	var id, mime string
+	if err == nil {
+		id, mime = first.Id, first.Mime
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		var closeErr error
+		defer func() { pw.CloseWithError(closeErr) }()
+		if err == io.EOF {
+			return
+		}
+		if len(first.Data) > 0 {
+			if _, werr := pw.Write(first.Data); werr != nil {
+				closeErr = werr
+				return
+			}
+		}
+		for {
+			req, rerr := stream.Recv()
+			if rerr == io.EOF {
+				return
+			}
+			if rerr != nil {
+				closeErr = rerr
+				return
+			}
+			if _, werr := pw.Write(req.Data); werr != nil {
+				closeErr = werr
+				return
+			}
+		}
+	}()
+
+	if err := s.peer.Save(stream.Context(), id, mime, pr); err != nil {
+		pr.Close()
+		return err
+	}
+	return stream.SendAndClose(new(proto.SaveResponse))
+}
+
+func (s *grpcServer) Log(stream proto.Peer_LogServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(new(proto.LogResponse))
+		}
+		if err != nil {
+			return err
+		}
+		line := new(Line)
+		if err := json.Unmarshal(req.Line, line); err != nil {
+			return err
+		}
+		if err := s.peer.Log(stream.Context(), req.Id, line); err != nil {
+			return err
+		}
+	}
+}
+
+func toPipeline(p *proto.PipelineItem) (*Pipeline, error) {
+	if p == nil {
+		return new(Pipeline), nil
+	}
+	config := new(backend.Config)
+	if len(p.Config) != 0 {
+		if err := json.Unmarshal(p.Config, config); err != nil {
+			return nil, err
+		}
+	}
+	return &Pipeline{
+		ID:      p.Id,
+		State:   fromProtoState(p.State),
+		Config:  config,
+		Timeout: p.Timeout,
+	}, nil
+}
+
+func fromPipeline(p *Pipeline) (*proto.PipelineItem, error) {
+	if p == nil {
+		return nil, nil
+	}
+	config, err := json.Marshal(p.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.PipelineItem{
+		Id:      p.ID,
+		State:   toProtoState(p.State),
+		Config:  config,
+		Timeout: p.Timeout,
+	}, nil
+}
+
+func toProtoState(s State) *proto.PipelineState {
+	return &proto.PipelineState{
+		Exited:   s.Exited,
+		ExitCode: int32(s.ExitCode),
+		Started:  s.Started,
+		Finished: s.Finished,
+		Error:    s.Error,
+	}
+}
+
+func fromProtoState(s *proto.PipelineState) State {
+	if s == nil {
+		return State{}
+	}
+	return State{
+		Exited:   s.Exited,
+		ExitCode: int(s.ExitCode),
+		Started:  s.Started,
+		Finished: s.Finished,
+		Error:    s.Error,
+	}
+}
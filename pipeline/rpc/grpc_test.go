@@ -0,0 +1,216 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// testPeer is a Peer backed by plain in-memory state, driven over a real
+// gRPC connection by grpcServer/grpcClient so the test exercises the actual
+// wire encoding (including the chunked Save stream), not a mock of either
+// side.
+type testPeer struct {
+	mu       sync.Mutex
+	pipeline *Pipeline
+	cancel   bool
+	extended int
+	updates  []State
+	saved    []byte
+	saveID   string
+	saveMime string
+	logged   []*Line
+	logID    string
+
+	gotMD metadata.MD
+}
+
+func (p *testPeer) Next(c context.Context, filter Filter) (*Pipeline, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pipeline, nil
+}
+
+func (p *testPeer) Notify(c context.Context, id string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cancel, nil
+}
+
+func (p *testPeer) Wait(c context.Context, id string) error {
+	return errors.New("not used by this test")
+}
+
+func (p *testPeer) Extend(c context.Context, id string) error {
+	md, _ := metadata.FromIncomingContext(c)
+	p.mu.Lock()
+	p.extended++
+	p.gotMD = md
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *testPeer) Update(c context.Context, id string, state State) error {
+	p.mu.Lock()
+	p.updates = append(p.updates, state)
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *testPeer) Save(c context.Context, id, mime string, file io.Reader) error {
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.saveID, p.saveMime, p.saved = id, mime, data
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *testPeer) Log(c context.Context, id string, line *Line) error {
+	p.mu.Lock()
+	p.logID = id
+	p.logged = append(p.logged, line)
+	p.mu.Unlock()
+	return nil
+}
+
+// dialTestGRPCServer starts peer behind RegisterGRPCServer on a real
+// localhost listener and returns a Peer connected to it with NewGRPCClient,
+// cleaning both up on test end.
+func dialTestGRPCServer(t *testing.T, peer Peer, opts ...Option) Peer {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	RegisterGRPCServer(srv, peer)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	cli, err := NewGRPCClient(lis.Addr().String(), opts...)
+	if err != nil {
+		t.Fatalf("NewGRPCClient: %v", err)
+	}
+	t.Cleanup(func() {
+		if closer, ok := cli.(io.Closer); ok {
+			closer.Close()
+		}
+	})
+	return cli
+}
+
+// TestGRPCClientServerRoundTrip drives NewGRPCClient through every Peer
+// method except Wait against a grpcServer backed by a real net.Listener and
+// grpc.Dial, including the chunked Save stream (a small chunk size splits the
+// payload across many messages), rather than exercising the generated proto
+// code in isolation.
+func TestGRPCClientServerRoundTrip(t *testing.T) {
+	peer := &testPeer{
+		pipeline: &Pipeline{ID: "pipeline-1", Timeout: 60},
+		cancel:   true,
+	}
+	cli := dialTestGRPCServer(t, peer, WithSaveChunkSize(4096))
+
+	ctx, done := context.WithTimeout(context.Background(), 10*time.Second)
+	defer done()
+
+	got, err := cli.Next(ctx, Filter{Platform: "linux/amd64"})
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if got.ID != "pipeline-1" {
+		t.Fatalf("Next: got id %q, want %q", got.ID, "pipeline-1")
+	}
+
+	cancelled, err := cli.Notify(ctx, "pipeline-1")
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if !cancelled {
+		t.Fatal("Notify: got false, want true")
+	}
+
+	if err := cli.Extend(ctx, "pipeline-1"); err != nil {
+		t.Fatalf("Extend: %v", err)
+	}
+	peer.mu.Lock()
+	extended := peer.extended
+	peer.mu.Unlock()
+	if extended != 1 {
+		t.Fatalf("Extend: peer saw %d calls, want 1", extended)
+	}
+
+	state := State{Exited: true, ExitCode: 1, Started: 100, Finished: 200}
+	if err := cli.Update(ctx, "pipeline-1", state); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	peer.mu.Lock()
+	gotUpdates := peer.updates
+	peer.mu.Unlock()
+	if len(gotUpdates) != 1 || gotUpdates[0] != state {
+		t.Fatalf("Update: peer saw %+v, want [%+v]", gotUpdates, state)
+	}
+
+	payload := bytes.Repeat([]byte("artifact-bytes-"), 10000)
+	if err := cli.Save(ctx, "pipeline-1", "application/octet-stream", bytes.NewReader(payload)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	peer.mu.Lock()
+	gotSaved, gotSaveID, gotSaveMime := peer.saved, peer.saveID, peer.saveMime
+	peer.mu.Unlock()
+	if !bytes.Equal(gotSaved, payload) {
+		t.Fatalf("Save: peer received %d bytes, want %d matching bytes", len(gotSaved), len(payload))
+	}
+	if gotSaveID != "pipeline-1" || gotSaveMime != "application/octet-stream" {
+		t.Fatalf("Save: peer saw id=%q mime=%q", gotSaveID, gotSaveMime)
+	}
+
+	line := &Line{Proc: "build", Time: 42, Out: "hello"}
+	if err := cli.Log(ctx, "pipeline-1", line); err != nil {
+		t.Fatalf("Log: %v", err)
+	}
+	peer.mu.Lock()
+	gotLogID, gotLogged := peer.logID, peer.logged
+	peer.mu.Unlock()
+	if gotLogID != "pipeline-1" || len(gotLogged) != 1 || gotLogged[0].Out != "hello" {
+		t.Fatalf("Log: peer saw id=%q logged=%+v", gotLogID, gotLogged)
+	}
+}
+
+// TestGRPCClientSendsAuthMetadata verifies WithToken/WithAgentID actually
+// land on the outgoing gRPC metadata the peer receives, by reading it back
+// out of the server handler's incoming context instead of just checking
+// grpcClient.ctx's return value in isolation.
+func TestGRPCClientSendsAuthMetadata(t *testing.T) {
+	peer := &testPeer{pipeline: &Pipeline{ID: "pipeline-1"}}
+	cli := dialTestGRPCServer(t, peer, WithToken("s3cr3t"), WithAgentID("agent-7"))
+
+	ctx, done := context.WithTimeout(context.Background(), 10*time.Second)
+	defer done()
+	if err := cli.Extend(ctx, "pipeline-1"); err != nil {
+		t.Fatalf("Extend: %v", err)
+	}
+
+	peer.mu.Lock()
+	md := peer.gotMD
+	peer.mu.Unlock()
+
+	if got := md.Get("authorization"); len(got) != 1 || got[0] != "Bearer s3cr3t" {
+		t.Errorf("authorization metadata = %v, want [%q]", got, "Bearer s3cr3t")
+	}
+	if got := md.Get("x-agent-id"); len(got) != 1 || got[0] != "agent-7" {
+		t.Errorf("x-agent-id metadata = %v, want [%q]", got, "agent-7")
+	}
+}
@@ -0,0 +1,112 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// delayHandler answers every call after delay, replying from its own
+// goroutine so it never blocks the jsonrpc2 connection's read loop — the
+// same way a real server handler would dispatch work, and a prerequisite
+// for more than one call to be in flight on a connection at once. It also
+// tracks how many calls it is serving at once so tests can tell concurrent
+// dispatch from calls that serialize behind one another.
+type delayHandler struct {
+	delay time.Duration
+
+	mu          sync.Mutex
+	inflight    int
+	maxInflight int
+}
+
+func (h *delayHandler) Handle(c context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	go func() {
+		h.mu.Lock()
+		h.inflight++
+		if h.inflight > h.maxInflight {
+			h.maxInflight = h.inflight
+		}
+		h.mu.Unlock()
+		defer func() {
+			h.mu.Lock()
+			h.inflight--
+			h.mu.Unlock()
+		}()
+
+		select {
+		case <-time.After(h.delay):
+			conn.Reply(c, req.ID, new(Pipeline))
+		case <-c.Done():
+			conn.ReplyWithError(c, req.ID, &jsonrpc2.Error{Message: c.Err().Error()})
+		}
+	}()
+}
+
+// newInprocClient returns a Client wired to handler entirely in-process via
+// NewInprocDialer, with no network involved.
+func newInprocClient(t *testing.T, handler jsonrpc2.Handler) *Client {
+	t.Helper()
+	cli, err := NewClient("inproc://test", WithDialer(NewInprocDialer(handler)))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { cli.Close() })
+	return cli
+}
+
+// TestClientConcurrentCalls verifies that two Next calls issued at the same
+// time are both in flight on the connection at once rather than one
+// completing before the other is dispatched.
+func TestClientConcurrentCalls(t *testing.T) {
+	h := &delayHandler{delay: 50 * time.Millisecond}
+	cli := newInprocClient(t, h)
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cli.Next(context.Background(), Filter{}); err != nil {
+				t.Errorf("Next: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	if elapsed >= 2*h.delay {
+		t.Fatalf("two concurrent Next calls took %v, want well under %v if dispatched concurrently", elapsed, 2*h.delay)
+	}
+	h.mu.Lock()
+	max := h.maxInflight
+	h.mu.Unlock()
+	if max < 2 {
+		t.Fatalf("handler never saw more than %d call in flight at once, want 2", max)
+	}
+}
+
+// TestClientCallRespectsContextCancellation verifies a call unblocks as
+// soon as its context is cancelled instead of waiting for the handler.
+func TestClientCallRespectsContextCancellation(t *testing.T) {
+	h := &delayHandler{delay: time.Minute}
+	cli := newInprocClient(t, h)
+
+	c, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := cli.Next(c, Filter{})
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("Next: expected an error from the cancelled context, got nil")
+	}
+	if elapsed >= h.delay {
+		t.Fatalf("Next took %v, want it to return promptly once the context timed out", elapsed)
+	}
+}
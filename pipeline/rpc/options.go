@@ -0,0 +1,97 @@
+package rpc
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+// defaultSaveChunkSize is the frame size Save splits an artifact into when
+// no WithSaveChunkSize option is given.
+const defaultSaveChunkSize = 1 << 20 // 1 MiB
+
+// defaultBackoffFactor is the multiplier applied to the backoff base on
+// each successive reconnect attempt.
+const defaultBackoffFactor = 2.0
+
+// defaultBackoffCap is the default ceiling on reconnect backoff. It is
+// well above defaultBackoff so attempts actually grow exponentially out of
+// the box instead of immediately clamping to the base.
+const defaultBackoffCap = 5 * time.Minute
+
+// options holds configuration shared by the jsonrpc2 and gRPC Peer client
+// implementations.
+type options struct {
+	retry         int
+	backoffBase   time.Duration
+	backoffCap    time.Duration
+	backoffFactor float64
+	saveChunkSize int
+	dialer        Dialer
+	tlsConfig     *tls.Config
+	token         string
+	agentID       string
+}
+
+// newOptions returns the default client options.
+func newOptions() *options {
+	return &options{
+		retry:         defaultRetryClount,
+		backoffBase:   defaultBackoff,
+		backoffCap:    defaultBackoffCap,
+		backoffFactor: defaultBackoffFactor,
+		saveChunkSize: defaultSaveChunkSize,
+	}
+}
+
+// Option configures a Peer client returned by NewClient or NewGRPCClient.
+type Option func(*options)
+
+// WithBackoff sets the base and cap of the exponential backoff used between
+// reconnect attempts. Each attempt waits a random duration up to
+// min(base*factor^attempt, cap).
+func WithBackoff(base, cap time.Duration) Option {
+	return func(o *options) {
+		o.backoffBase = base
+		o.backoffCap = cap
+	}
+}
+
+// WithDialer overrides automatic transport selection (by endpoint URL
+// scheme) with an explicit Dialer, most commonly NewInprocDialer for tests.
+func WithDialer(d Dialer) Option {
+	return func(o *options) {
+		o.dialer = d
+	}
+}
+
+// WithSaveChunkSize sets the frame size Save splits an artifact into before
+// streaming it to the server as a sequence of save.chunk calls.
+func WithSaveChunkSize(n int) Option {
+	return func(o *options) {
+		o.saveChunkSize = n
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for the websocket and HTTP
+// transports. It has no effect on the unix and inproc transports.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = cfg
+	}
+}
+
+// WithToken sends token as an `Authorization: Bearer <token>` header on the
+// websocket upgrade request and on every HTTP transport request.
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// WithAgentID sends id as an `X-Agent-Id` header so the server can
+// attribute the pipelines it hands out to a specific worker.
+func WithAgentID(id string) Option {
+	return func(o *options) {
+		o.agentID = id
+	}
+}
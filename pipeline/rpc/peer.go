@@ -2,15 +2,21 @@ package rpc
 
 import (
 	"context"
+	"errors"
 	"io"
 
 	"github.com/cncd/pipeline/pipeline/backend"
 )
 
+// ErrCancelled is returned by Peer.Wait when the pipeline was cancelled
+// before it completed.
+var ErrCancelled = errors.New("rpc: pipeline cancelled")
+
 type (
 	// Filter defines filters for fetching items from the queue.
 	Filter struct {
-		Platform string `json:"platform"`
+		Platform string            `json:"platform"`
+		Labels   map[string]string `json:"labels,omitempty"`
 	}
 
 	// State defines the pipeline state.
@@ -33,13 +39,20 @@ type (
 
 // Peer defines a peer-to-peer connection.
 type Peer interface {
-	// Next returns the next pipeline in the queue.
-	Next(c context.Context) (*Pipeline, error)
+	// Next returns the next pipeline in the queue matching filter. An agent
+	// advertises its capabilities (platform, arch, gpu, ...) via
+	// filter.Labels so the server only hands it pipelines it can run.
+	Next(c context.Context, filter Filter) (*Pipeline, error)
 
 	// Notify returns true if the pipeline should be cancelled.
+	// Deprecated: poll-based, prefer Wait.
 	// TODO: rename to Done
 	Notify(c context.Context, id string) (bool, error)
 
+	// Wait blocks until the pipeline is done or cancelled, returning
+	// ErrCancelled if it was cancelled. It replaces busy-polling Notify.
+	Wait(c context.Context, id string) error
+
 	// Extend extends the pipeline deadline
 	Extend(c context.Context, id string) error
 
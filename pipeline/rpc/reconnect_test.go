@@ -0,0 +1,70 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// blockingHandler holds every request open until release is closed, then
+// replies to all of them, so a test can line up several in-flight calls
+// before letting the server respond to any of them.
+type blockingHandler struct {
+	release chan struct{}
+}
+
+func (h *blockingHandler) Handle(c context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	go func() {
+		select {
+		case <-h.release:
+			conn.Reply(c, req.ID, new(Pipeline))
+		case <-c.Done():
+			conn.ReplyWithError(c, req.ID, &jsonrpc2.Error{Message: c.Err().Error()})
+		}
+	}()
+}
+
+// TestClientReconnectReplaysAllPendingCalls verifies that when a connection
+// drop is discovered, every call still in flight is replayed against the
+// new connection, not only the one call whose failure triggered the
+// reconnect.
+func TestClientReconnectReplaysAllPendingCalls(t *testing.T) {
+	h := &blockingHandler{release: make(chan struct{})}
+	dialer := NewInprocDialer(h)
+
+	cli, err := NewClient("inproc://test", WithDialer(dialer), WithBackoff(time.Millisecond, 10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	defer cli.Close()
+
+	// Kill the live connection out from under the client so the next calls
+	// fail with a connectivity error and go through reconnectAndReplay.
+	cli.getConn().Close()
+
+	const n = 3
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = cli.Next(context.Background(), Filter{})
+		}(i)
+	}
+
+	// Give every goroutine a chance to register as pending and hit the
+	// reconnect path before the server is allowed to answer.
+	time.Sleep(50 * time.Millisecond)
+	close(h.release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("call %d: %v", i, err)
+		}
+	}
+}
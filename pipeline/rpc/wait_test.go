@@ -0,0 +1,121 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// waitPushHandler acks every methodWait call, then once it has seen
+// wantWaiters of them pushes notify (methodDone or methodCancel) for the
+// id carried on the request, mirroring a real server answering a pending
+// Wait once the pipeline it's tracking finishes or is cancelled.
+type waitPushHandler struct {
+	wantWaiters int
+	notify      string
+
+	mu   sync.Mutex
+	seen int
+}
+
+func (h *waitPushHandler) Handle(c context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if req.Method != methodWait {
+		return
+	}
+	conn.Reply(c, req.ID, nil)
+
+	h.mu.Lock()
+	h.seen++
+	n := h.seen
+	h.mu.Unlock()
+	if n != h.wantWaiters {
+		return
+	}
+
+	var id string
+	if err := json.Unmarshal(*req.Params, &id); err != nil {
+		return
+	}
+	conn.Notify(c, h.notify, id)
+}
+
+// TestClientWaitResolvesAllConcurrentWaiters verifies that two concurrent
+// Wait calls for the same pipeline id both unblock, with no error, once
+// the server pushes a single methodDone notification for that id.
+func TestClientWaitResolvesAllConcurrentWaiters(t *testing.T) {
+	h := &waitPushHandler{wantWaiters: 2, notify: methodDone}
+	cli := newInprocClient(t, h)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = cli.Wait(context.Background(), "pipeline-1")
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Wait %d: %v", i, err)
+		}
+	}
+}
+
+// TestClientWaitReturnsErrCancelled verifies Wait returns ErrCancelled when
+// the server pushes a methodCancel notification for the waited-on id.
+func TestClientWaitReturnsErrCancelled(t *testing.T) {
+	h := &waitPushHandler{wantWaiters: 1, notify: methodCancel}
+	cli := newInprocClient(t, h)
+
+	if err := cli.Wait(context.Background(), "pipeline-1"); !errors.Is(err, ErrCancelled) {
+		t.Fatalf("Wait: got %v, want ErrCancelled", err)
+	}
+}
+
+// ackOnlyHandler acks methodWait but never pushes a done/cancel
+// notification, so the caller's Wait only returns if something else
+// (Close) unblocks it.
+type ackOnlyHandler struct{}
+
+func (ackOnlyHandler) Handle(c context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if req.Method == methodWait {
+		conn.Reply(c, req.ID, nil)
+	}
+}
+
+// TestClientCloseUnblocksPendingWait verifies Close resolves a Wait call
+// still pending on a notification that will now never arrive, instead of
+// leaving it blocked past shutdown.
+func TestClientCloseUnblocksPendingWait(t *testing.T) {
+	cli, err := NewClient("inproc://test", WithDialer(NewInprocDialer(ackOnlyHandler{})))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	waitErr := make(chan error, 1)
+	go func() { waitErr <- cli.Wait(context.Background(), "pipeline-1") }()
+
+	// Give the Wait call time to register before closing out from under it.
+	time.Sleep(20 * time.Millisecond)
+	if err := cli.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	select {
+	case err := <-waitErr:
+		if !errors.Is(err, io.ErrClosedPipe) {
+			t.Fatalf("Wait returned %v after Close, want io.ErrClosedPipe", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return within 1s of Close")
+	}
+}